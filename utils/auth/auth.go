@@ -1,11 +1,13 @@
 package auth
 
 import (
-	"crypto/rsa"
-	"errors"
+	"crypto"
 	"fmt"
 	"io/ioutil"
+	"os"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/dgrijalva/jwt-go"
 )
@@ -15,16 +17,32 @@ type Module struct {
 	lock sync.RWMutex
 
 	// For internal use
-	config *Config
+	config        *Config
+	signingMethod jwt.SigningMethod
+	keys          *KeyStore // local signing/verification keys, nil when verification relies solely on a fetched JWKS
+	kid           string
+	jwksCache     *jwksCacheEntry // remote keys fetched via JWKS, populated in runner mode for RS/PS/ES families
 }
 
 // Config is the object used to configure the auth module
 type Config struct {
 	// JWT related stuff
-	JWTAlgorithm JWTAlgorithm
-	PublicKey    *rsa.PublicKey  // for RSA
-	PrivateKey   *rsa.PrivateKey // for RSA
-	Secret       string          // for HSA
+	SignMethod     JWTAlgorithm
+	PublicKeyPath  string // path to the PEM encoded public key, for RSA/ECDSA
+	PrivateKeyPath string // path to the PEM encoded private key, for RSA/ECDSA
+	PublicKey      crypto.PublicKey
+	PrivateKey     crypto.PrivateKey
+	Secret         string        // for the HMAC family
+	Kid            string        // identifies the active key in the JWKS, defaults to "default"
+	JWKSEndpoint   string        // URL the runner fetches the JWKS from, e.g. http://server/.well-known/jwks.json
+	WatchEndpoint  string        // URL the runner long-polls for key set changes, falls back to polling JWKSEndpoint if unset or unreachable
+	KeyGracePeriod time.Duration // how long a retired key still verifies tokens, defaults to 24h
+
+	// AutoGenerateKeys lets New() mint a fresh keypair at PrivateKeyPath/PublicKeyPath
+	// when Mode is Server and those paths don't exist yet. Off by default - a missing
+	// key more often means a misconfigured path than a fresh install, and silently
+	// minting a new identity in that case would mask the mistake instead of failing loudly.
+	AutoGenerateKeys bool
 
 	// User authentication
 	UserName string
@@ -36,15 +54,37 @@ type Config struct {
 	Mode OperatingMode
 }
 
-// JWTAlgorithm describes the jwt algorithm to use
+// JWTAlgorithm describes the jwt signing algorithm to use
 type JWTAlgorithm string
 
 const (
-	// RSA256 is used for rsa256 algorithm
-	RSA256 JWTAlgorithm = "rsa256"
+	// RS256 is used for the RSASSA-PKCS1-v1_5 256 algorithm
+	RS256 JWTAlgorithm = "RS256"
+	// RS384 is used for the RSASSA-PKCS1-v1_5 384 algorithm
+	RS384 JWTAlgorithm = "RS384"
+	// RS512 is used for the RSASSA-PKCS1-v1_5 512 algorithm
+	RS512 JWTAlgorithm = "RS512"
+
+	// PS256 is used for the RSASSA-PSS 256 algorithm
+	PS256 JWTAlgorithm = "PS256"
+	// PS384 is used for the RSASSA-PSS 384 algorithm
+	PS384 JWTAlgorithm = "PS384"
+	// PS512 is used for the RSASSA-PSS 512 algorithm
+	PS512 JWTAlgorithm = "PS512"
+
+	// ES256 is used for the ECDSA 256 algorithm
+	ES256 JWTAlgorithm = "ES256"
+	// ES384 is used for the ECDSA 384 algorithm
+	ES384 JWTAlgorithm = "ES384"
+	// ES512 is used for the ECDSA 512 algorithm
+	ES512 JWTAlgorithm = "ES512"
 
-	// HS256 is used for hs256 algorithm
-	HS256 JWTAlgorithm = "hs256"
+	// HS256 is used for the HMAC 256 algorithm
+	HS256 JWTAlgorithm = "HS256"
+	// HS384 is used for the HMAC 384 algorithm
+	HS384 JWTAlgorithm = "HS384"
+	// HS512 is used for the HMAC 512 algorithm
+	HS512 JWTAlgorithm = "HS512"
 )
 
 // OperatingMode indicates the mode of operation
@@ -58,48 +98,241 @@ const (
 	Server OperatingMode = "server"
 )
 
-// New creates a new instance of the auth module
+// New creates a new instance of the auth module. jwtPublicKeyPath/jwtPrivatePath
+// take precedence when set; otherwise config.PublicKeyPath/PrivateKeyPath are used,
+// so a caller may configure paths either way without one silently discarding the other.
 func New(config *Config, jwtPublicKeyPath, jwtPrivatePath string) (*Module, error) {
-	m := &Module{config: config}
+	if jwtPublicKeyPath == "" {
+		jwtPublicKeyPath = config.PublicKeyPath
+	}
+	if jwtPrivatePath == "" {
+		jwtPrivatePath = config.PrivateKeyPath
+	}
+	config.PublicKeyPath = jwtPublicKeyPath
+	config.PrivateKeyPath = jwtPrivatePath
+
+	m := &Module{config: config, kid: config.Kid}
+	if m.kid == "" {
+		m.kid = "default"
+	}
+
+	signingMethod := jwt.GetSigningMethod(string(config.SignMethod))
+	if signingMethod == nil {
+		return nil, fmt.Errorf("invalid sign method (%s) provided", config.SignMethod)
+	}
+	m.signingMethod = signingMethod
 
-	if config.JWTAlgorithm == RSA256 {
-		// The runner needs to fetch the public key from the server for rsa
+	switch {
+	case strings.HasPrefix(string(config.SignMethod), "HS"):
+		if config.Secret == "" {
+			return nil, fmt.Errorf("secret cannot be empty for sign method (%s)", config.SignMethod)
+		}
+		m.keys = newKeyStore(config.KeyGracePeriod)
+		m.keys.setCurrent(m.kid, config.SignMethod, []byte(config.Secret), []byte(config.Secret))
+
+	case strings.HasPrefix(string(config.SignMethod), "ES"):
+		// The runner needs to fetch the public key from the server
 		if config.Mode == Runner {
-			// Attempt fetching public key
 			if success := m.fetchPublicKey(); !success {
-				return nil, errors.New("could not initialise the auth module")
+				return nil, fmt.Errorf("could not initialise the auth module")
 			}
+			go m.watchKeys()
+			break
+		}
 
-			// Start the public key fetch routine
-			go m.routineGetPublicKey()
+		// The server reads the keys from local storage, optionally generating them on
+		// first boot (AutoGenerateKeys) so a fresh install can just work
+		if config.AutoGenerateKeys && !fileExists(jwtPrivatePath) {
+			if err := GenerateKeyPair(config.SignMethod, 0, jwtPrivatePath, jwtPublicKeyPath); err != nil {
+				return nil, fmt.Errorf("error auto-generating keypair - %v", err)
+			}
 		}
-		// The server need to fetch the keys from local storage
-		if config.Mode == Server {
-			signBytes, err := ioutil.ReadFile(jwtPrivatePath)
+
+		signBytes, err := ioutil.ReadFile(jwtPrivatePath)
+		if err != nil {
+			return nil, fmt.Errorf("error reading private key from path - %v", err)
+		}
+
+		privateKey, err := jwt.ParseECPrivateKeyFromPEM(signBytes)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing private key - %v", err)
+		}
+
+		publicKey := &privateKey.PublicKey
+		if jwtPublicKeyPath != "" {
+			verifyBytes, err := ioutil.ReadFile(jwtPublicKeyPath)
 			if err != nil {
-				fmt.Errorf("error reading private key from path")
+				return nil, fmt.Errorf("error reading public key from path - %v", err)
 			}
 
-			privateKey, err := jwt.ParseRSAPrivateKeyFromPEM(signBytes)
+			publicKey, err = jwt.ParseECPublicKeyFromPEM(verifyBytes)
 			if err != nil {
-				fmt.Errorf("error parsing private key")
+				return nil, fmt.Errorf("error parsing public key - %v", err)
+			}
+		}
+
+		config.PrivateKey = privateKey
+		config.PublicKey = publicKey
+		m.keys = newKeyStore(config.KeyGracePeriod)
+		m.keys.setCurrent(m.kid, config.SignMethod, privateKey, publicKey)
+
+	default:
+		// RS and PS families both use an RSA keypair
+		if config.Mode == Runner {
+			if success := m.fetchPublicKey(); !success {
+				return nil, fmt.Errorf("could not initialise the auth module")
+			}
+			go m.watchKeys()
+			break
+		}
+
+		// The server reads the keys from local storage, optionally generating them on
+		// first boot (AutoGenerateKeys) so a fresh install can just work
+		if config.AutoGenerateKeys && !fileExists(jwtPrivatePath) {
+			if err := GenerateKeyPair(config.SignMethod, 0, jwtPrivatePath, jwtPublicKeyPath); err != nil {
+				return nil, fmt.Errorf("error auto-generating keypair - %v", err)
 			}
+		}
 
+		signBytes, err := ioutil.ReadFile(jwtPrivatePath)
+		if err != nil {
+			return nil, fmt.Errorf("error reading private key from path - %v", err)
+		}
+
+		privateKey, err := jwt.ParseRSAPrivateKeyFromPEM(signBytes)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing private key - %v", err)
+		}
+
+		publicKey := &privateKey.PublicKey
+		if jwtPublicKeyPath != "" {
 			verifyBytes, err := ioutil.ReadFile(jwtPublicKeyPath)
 			if err != nil {
-				fmt.Errorf("error reading public key from path")
-
+				return nil, fmt.Errorf("error reading public key from path - %v", err)
 			}
 
-			publicKey, err := jwt.ParseRSAPublicKeyFromPEM(verifyBytes)
+			publicKey, err = jwt.ParseRSAPublicKeyFromPEM(verifyBytes)
 			if err != nil {
-				fmt.Errorf("error parsing public key")
+				return nil, fmt.Errorf("error parsing public key - %v", err)
 			}
-			config.PublicKey = publicKey
-			config.PrivateKey = privateKey
-			m.config = config
 		}
+
+		config.PrivateKey = privateKey
+		config.PublicKey = publicKey
+		m.keys = newKeyStore(config.KeyGracePeriod)
+		m.keys.setCurrent(m.kid, config.SignMethod, privateKey, publicKey)
 	}
 
+	m.config = config
 	return m, nil
 }
+
+// SignToken signs the provided claims with the module's current key, stamping
+// its kid into the JWT header so verifiers (including other launchpad instances
+// after a key rotation) know which key to check it against
+func (m *Module) SignToken(claims jwt.Claims) (string, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	if m.keys == nil {
+		return "", fmt.Errorf("auth module has no local signing key configured")
+	}
+
+	kid, _, signKey, err := m.keys.Current()
+	if err != nil {
+		return "", err
+	}
+
+	token := jwt.NewWithClaims(m.signingMethod, claims)
+	token.Header["kid"] = kid
+	return token.SignedString(signKey)
+}
+
+// VerifyToken parses and verifies the provided token string, returning the parsed
+// token on success. The verification key is chosen by the token's kid header; if
+// that's missing or unknown, every currently active key is tried as a fallback -
+// this lets verification keep working for tokens signed just before a rotation.
+func (m *Module) VerifyToken(tokenString string) (*jwt.Token, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	candidates, err := m.candidateVerifyKeys(tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	var lastErr error
+	for _, key := range candidates {
+		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+			if token.Method.Alg() != m.signingMethod.Alg() {
+				return nil, fmt.Errorf("unexpected signing method (%s)", token.Method.Alg())
+			}
+			return key, nil
+		})
+		if err == nil && token.Valid {
+			return token, nil
+		}
+		lastErr = err
+	}
+
+	return nil, lastErr
+}
+
+// candidateVerifyKeys returns the keys worth trying for tokenString, the key
+// matching its kid header (if any) first, followed by every other active key.
+func (m *Module) candidateVerifyKeys(tokenString string) ([]interface{}, error) {
+	unverified, _, err := new(jwt.Parser).ParseUnverified(tokenString, jwt.MapClaims{})
+	if err != nil {
+		return nil, fmt.Errorf("error parsing token - %v", err)
+	}
+	kid, _ := unverified.Header["kid"].(string)
+
+	var ordered []interface{}
+	seen := map[string]bool{}
+	add := func(key interface{}) {
+		if key == nil {
+			return
+		}
+		id := keyIdentity(key)
+		if !seen[id] {
+			seen[id] = true
+			ordered = append(ordered, key)
+		}
+	}
+
+	if m.jwksCache != nil {
+		add(m.jwksCache.keys[kid])
+		for _, key := range m.jwksCache.keys {
+			add(key)
+		}
+	}
+
+	if m.keys != nil {
+		if key, _, ok := m.keys.Verify(kid); ok {
+			add(key)
+		}
+		for _, key := range m.keys.ActiveVerifyKeys() {
+			add(key)
+		}
+	}
+
+	if len(ordered) == 0 {
+		return nil, fmt.Errorf("no verification key configured for this auth module")
+	}
+	return ordered, nil
+}
+
+// fileExists reports whether path exists and is readable
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// keyIdentity returns a comparable identifier for a verify key so candidateVerifyKeys
+// can dedupe them - []byte secrets aren't map-key safe, unlike the RSA/ECDSA pointers.
+func keyIdentity(key interface{}) string {
+	if secret, ok := key.([]byte); ok {
+		return "hs:" + string(secret)
+	}
+	return fmt.Sprintf("%p", key)
+}
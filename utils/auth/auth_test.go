@@ -0,0 +1,96 @@
+package auth
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+func TestSignVerifyRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "auth-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, alg := range []JWTAlgorithm{RS256, ES256, HS256} {
+		alg := alg
+		t.Run(string(alg), func(t *testing.T) {
+			config := &Config{SignMethod: alg, Mode: Server}
+
+			privPath := filepath.Join(dir, string(alg)+"_priv.pem")
+			pubPath := filepath.Join(dir, string(alg)+"_pub.pem")
+
+			if alg == HS256 {
+				config.Secret = "test-secret"
+			} else {
+				if err := GenerateKeyPair(alg, 0, privPath, pubPath); err != nil {
+					t.Fatalf("error generating keypair - %v", err)
+				}
+			}
+
+			m, err := New(config, pubPath, privPath)
+			if err != nil {
+				t.Fatalf("error creating module - %v", err)
+			}
+
+			tokenString, err := m.SignToken(jwt.MapClaims{"sub": "u1"})
+			if err != nil {
+				t.Fatalf("error signing token - %v", err)
+			}
+
+			token, err := m.VerifyToken(tokenString)
+			if err != nil {
+				t.Fatalf("error verifying token - %v", err)
+			}
+			if !token.Valid {
+				t.Fatal("expected token to be valid")
+			}
+		})
+	}
+}
+
+func TestVerifyTokenRejectsAlgorithmMismatch(t *testing.T) {
+	hs256, err := New(&Config{SignMethod: HS256, Secret: "shh", Mode: Server}, "", "")
+	if err != nil {
+		t.Fatalf("error creating hs256 module - %v", err)
+	}
+
+	hs384, err := New(&Config{SignMethod: HS384, Secret: "shh", Mode: Server}, "", "")
+	if err != nil {
+		t.Fatalf("error creating hs384 module - %v", err)
+	}
+
+	tokenString, err := hs256.SignToken(jwt.MapClaims{"sub": "u1"})
+	if err != nil {
+		t.Fatalf("error signing token - %v", err)
+	}
+
+	if _, err := hs384.VerifyToken(tokenString); err == nil {
+		t.Fatal("expected verification to fail for a token signed with a different algorithm")
+	}
+}
+
+func TestVerifyTokenRejectsWrongKey(t *testing.T) {
+	signer, err := New(&Config{SignMethod: HS256, Secret: "correct-secret", Mode: Server}, "", "")
+	if err != nil {
+		t.Fatalf("error creating signer module - %v", err)
+	}
+
+	verifier, err := New(&Config{SignMethod: HS256, Secret: "different-secret", Mode: Server}, "", "")
+	if err != nil {
+		t.Fatalf("error creating verifier module - %v", err)
+	}
+
+	tokenString, err := signer.SignToken(jwt.MapClaims{"sub": "u1"})
+	if err != nil {
+		t.Fatalf("error signing token - %v", err)
+	}
+
+	if _, err := verifier.VerifyToken(tokenString); err == nil {
+		t.Fatal("expected verification to fail for a token signed with a different key")
+	}
+}
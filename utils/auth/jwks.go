@@ -0,0 +1,272 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultJWKSMaxAge is used for the Cache-Control header when the server serves the
+// key set, and as the fallback refresh interval when a runner can't parse it
+const defaultJWKSMaxAge = 5 * time.Minute
+
+// jwksClient bounds how long a single JWKS poll can take, so a peer that stops
+// responding mid-request doesn't hang the poll loop indefinitely
+var jwksClient = &http.Client{Timeout: 10 * time.Second}
+
+// JSONWebKey is a single entry of a JSON Web Key Set (RFC 7517)
+type JSONWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg,omitempty"`
+	Use string `json:"use,omitempty"`
+
+	// RSA specific
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+
+	// EC specific
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JSONWebKeySet is the `/.well-known/jwks.json` document
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// jwksCacheEntry holds a fetched key set on the runner, indexed by kid
+type jwksCacheEntry struct {
+	keys      map[string]interface{}
+	expiresAt time.Time
+}
+
+// JWKSHandler returns an http.HandlerFunc that serves the module's active public
+// key(s) as a JSON Web Key Set. It is meant to be mounted at `/.well-known/jwks.json`
+// on the server so runners (and other external services) can fetch and cache it.
+func (m *Module) JWKSHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		m.lock.RLock()
+		set, err := m.buildJWKS()
+		m.lock.RUnlock()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", fmt.Sprintf("max-age=%d", int(defaultJWKSMaxAge.Seconds())))
+		_ = json.NewEncoder(w).Encode(set)
+	}
+}
+
+// buildJWKS assembles the JSON Web Key Set for every key this module currently
+// verifies with - the current signer plus any keys still inside their grace
+// period - so a rotation never breaks tokens issued by the previous key.
+// Callers must hold at least a read lock.
+func (m *Module) buildJWKS() (*JSONWebKeySet, error) {
+	if m.keys == nil {
+		return nil, fmt.Errorf("no public key configured for this auth module")
+	}
+
+	active := m.keys.ActiveVerifyKeys()
+	if len(active) == 0 {
+		return nil, fmt.Errorf("no public key configured for this auth module")
+	}
+
+	set := &JSONWebKeySet{Keys: make([]JSONWebKey, 0, len(active))}
+	for kid, verifyKey := range active {
+		key, err := publicKeyToJWK(kid, string(m.config.SignMethod), verifyKey)
+		if err != nil {
+			continue
+		}
+		set.Keys = append(set.Keys, *key)
+	}
+
+	return set, nil
+}
+
+// publicKeyToJWK converts an RSA or ECDSA public key into its JWK representation
+func publicKeyToJWK(kid, alg string, key interface{}) (*JSONWebKey, error) {
+	switch pub := key.(type) {
+	case *rsa.PublicKey:
+		return &JSONWebKey{
+			Kty: "RSA",
+			Kid: kid,
+			Alg: alg,
+			Use: "sig",
+			N:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}, nil
+
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		return &JSONWebKey{
+			Kty: "EC",
+			Kid: kid,
+			Alg: alg,
+			Use: "sig",
+			Crv: curveName(pub.Curve),
+			X:   base64.RawURLEncoding.EncodeToString(leftPad(pub.X.Bytes(), size)),
+			Y:   base64.RawURLEncoding.EncodeToString(leftPad(pub.Y.Bytes(), size)),
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported key type for jwks")
+	}
+}
+
+// publicKey parses a single JWK entry back into an RSA or ECDSA public key
+func (k JSONWebKey) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		nBytes, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid modulus for kid %s - %v", k.Kid, err)
+		}
+		eBytes, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exponent for kid %s - %v", k.Kid, err)
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(nBytes),
+			E: int(new(big.Int).SetBytes(eBytes).Int64()),
+		}, nil
+
+	case "EC":
+		curve := curveByName(k.Crv)
+		if curve == nil {
+			return nil, fmt.Errorf("unsupported curve (%s) for kid %s", k.Crv, k.Kid)
+		}
+		xBytes, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("invalid x coordinate for kid %s - %v", k.Kid, err)
+		}
+		yBytes, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("invalid y coordinate for kid %s - %v", k.Kid, err)
+		}
+		return &ecdsa.PublicKey{
+			Curve: curve,
+			X:     new(big.Int).SetBytes(xBytes),
+			Y:     new(big.Int).SetBytes(yBytes),
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported key type (%s) for kid %s", k.Kty, k.Kid)
+	}
+}
+
+func curveName(curve elliptic.Curve) string {
+	switch curve {
+	case elliptic.P256():
+		return "P-256"
+	case elliptic.P384():
+		return "P-384"
+	case elliptic.P521():
+		return "P-521"
+	default:
+		return ""
+	}
+}
+
+func curveByName(name string) elliptic.Curve {
+	switch name {
+	case "P-256":
+		return elliptic.P256()
+	case "P-384":
+		return elliptic.P384()
+	case "P-521":
+		return elliptic.P521()
+	default:
+		return nil
+	}
+}
+
+func leftPad(b []byte, size int) []byte {
+	if len(b) >= size {
+		return b
+	}
+	padded := make([]byte, size)
+	copy(padded[size-len(b):], b)
+	return padded
+}
+
+// fetchPublicKey fetches the JWKS from the configured endpoint and caches it;
+// tokens that don't carry a `kid` header are checked against every cached key.
+// It is called once on startup before the runner can verify any tokens.
+func (m *Module) fetchPublicKey() bool {
+	if err := m.refreshJWKS(); err != nil {
+		return false
+	}
+	return true
+}
+
+// routineGetPublicKey periodically refreshes the cached JWKS, honouring the
+// Cache-Control max-age returned by the server.
+func (m *Module) routineGetPublicKey() {
+	for {
+		m.lock.RLock()
+		wait := time.Until(m.jwksCache.expiresAt)
+		m.lock.RUnlock()
+		if wait <= 0 {
+			wait = defaultJWKSMaxAge
+		}
+
+		time.Sleep(wait)
+		_ = m.refreshJWKS()
+	}
+}
+
+// refreshJWKS fetches the key set from `Config.JWKSEndpoint` and swaps it into the cache
+func (m *Module) refreshJWKS() error {
+	resp, err := jwksClient.Get(m.config.JWKSEndpoint)
+	if err != nil {
+		return fmt.Errorf("error fetching jwks - %v", err)
+	}
+	defer resp.Body.Close()
+
+	var set JSONWebKeySet
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return fmt.Errorf("error decoding jwks - %v", err)
+	}
+
+	keys := make(map[string]interface{}, len(set.Keys))
+	for _, jwk := range set.Keys {
+		key, err := jwk.publicKey()
+		if err != nil {
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.jwksCache = &jwksCacheEntry{keys: keys, expiresAt: time.Now().Add(parseMaxAge(resp.Header.Get("Cache-Control")))}
+	return nil
+}
+
+// parseMaxAge extracts the max-age directive from a Cache-Control header,
+// falling back to defaultJWKSMaxAge if it's missing or malformed.
+func parseMaxAge(header string) time.Duration {
+	for _, directive := range strings.Split(header, ",") {
+		directive = strings.TrimSpace(directive)
+		if !strings.HasPrefix(directive, "max-age=") {
+			continue
+		}
+		if secs, err := strconv.Atoi(strings.TrimPrefix(directive, "max-age=")); err == nil {
+			return time.Duration(secs) * time.Second
+		}
+	}
+	return defaultJWKSMaxAge
+}
@@ -0,0 +1,77 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+)
+
+func TestJWKRoundTripRSA(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("error generating rsa key - %v", err)
+	}
+
+	jwk, err := publicKeyToJWK("kid-1", string(RS256), &privateKey.PublicKey)
+	if err != nil {
+		t.Fatalf("error encoding jwk - %v", err)
+	}
+
+	parsed, err := jwk.publicKey()
+	if err != nil {
+		t.Fatalf("error decoding jwk - %v", err)
+	}
+
+	pub, ok := parsed.(*rsa.PublicKey)
+	if !ok {
+		t.Fatalf("expected *rsa.PublicKey, got %T", parsed)
+	}
+	if pub.E != privateKey.PublicKey.E || pub.N.Cmp(privateKey.PublicKey.N) != 0 {
+		t.Fatal("round-tripped rsa public key does not match the original")
+	}
+}
+
+func TestJWKRoundTripEC(t *testing.T) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("error generating ec key - %v", err)
+	}
+
+	jwk, err := publicKeyToJWK("kid-1", string(ES256), &privateKey.PublicKey)
+	if err != nil {
+		t.Fatalf("error encoding jwk - %v", err)
+	}
+
+	parsed, err := jwk.publicKey()
+	if err != nil {
+		t.Fatalf("error decoding jwk - %v", err)
+	}
+
+	pub, ok := parsed.(*ecdsa.PublicKey)
+	if !ok {
+		t.Fatalf("expected *ecdsa.PublicKey, got %T", parsed)
+	}
+	if pub.X.Cmp(privateKey.PublicKey.X) != 0 || pub.Y.Cmp(privateKey.PublicKey.Y) != 0 {
+		t.Fatal("round-tripped ec public key does not match the original")
+	}
+}
+
+func TestBuildJWKSSkipsHMACKeys(t *testing.T) {
+	m, err := New(&Config{SignMethod: HS256, Secret: "shh", Mode: Server}, "", "")
+	if err != nil {
+		t.Fatalf("error creating module - %v", err)
+	}
+
+	// HS256 keys aren't RSA/EC, so buildJWKS should skip them rather than error
+	m.lock.RLock()
+	set, err := m.buildJWKS()
+	m.lock.RUnlock()
+	if err != nil {
+		t.Fatalf("error building jwks - %v", err)
+	}
+	if len(set.Keys) != 0 {
+		t.Fatalf("expected no jwk entries for an hmac key, got %d", len(set.Keys))
+	}
+}
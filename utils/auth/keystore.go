@@ -0,0 +1,275 @@
+package auth
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// defaultKeyGracePeriod is how long a retired key is still accepted for
+// verification after a newer key takes over as the signer
+const defaultKeyGracePeriod = 24 * time.Hour
+
+// keyEntry is a single named key held by a KeyStore
+type keyEntry struct {
+	alg       JWTAlgorithm
+	signKey   interface{} // nil once the key is retired - it becomes verify-only
+	verifyKey interface{}
+	retiredAt time.Time // zero value means the key is still active
+}
+
+// KeyStore holds the set of signing/verification keys a Module uses, indexed by
+// kid. Exactly one key is ever the "current" signer; older keys are kept around
+// as verify-only for gracePeriod so that tokens issued just before a rotation
+// still verify.
+type KeyStore struct {
+	mu          sync.RWMutex
+	keys        map[string]*keyEntry
+	currentKid  string
+	gracePeriod time.Duration
+	version     int
+	notify      chan struct{}
+}
+
+// newKeyStore creates an empty KeyStore. A gracePeriod of 0 falls back to defaultKeyGracePeriod.
+func newKeyStore(gracePeriod time.Duration) *KeyStore {
+	if gracePeriod <= 0 {
+		gracePeriod = defaultKeyGracePeriod
+	}
+	return &KeyStore{keys: map[string]*keyEntry{}, gracePeriod: gracePeriod, notify: make(chan struct{})}
+}
+
+// Version returns the number of times the key set has changed, so a watcher can
+// tell whether it has missed an update since it last looked
+func (s *KeyStore) Version() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.version
+}
+
+// WaitChan returns a channel that's closed the next time the key set changes
+func (s *KeyStore) WaitChan() <-chan struct{} {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.notify
+}
+
+// Snapshot returns the current version together with the channel that will be
+// closed on the next change, both read under a single lock acquisition. Taking
+// Version() and WaitChan() separately can race with a rotation in between the
+// two calls, leaving the caller waiting on a channel created after the change
+// it should have observed; Snapshot is immune to that.
+func (s *KeyStore) Snapshot() (version int, wait <-chan struct{}) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.version, s.notify
+}
+
+// bumpLocked records a change to the key set and wakes any goroutine blocked on
+// WaitChan. Callers must already hold s.mu for writing.
+func (s *KeyStore) bumpLocked() {
+	s.version++
+	close(s.notify)
+	s.notify = make(chan struct{})
+}
+
+// setCurrent registers a key and marks it as the current signer, without retiring
+// whatever was previously current. Used to seed the store on startup.
+func (s *KeyStore) setCurrent(kid string, alg JWTAlgorithm, signKey, verifyKey interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.keys[kid] = &keyEntry{alg: alg, signKey: signKey, verifyKey: verifyKey}
+	s.currentKid = kid
+	s.bumpLocked()
+}
+
+// Current returns the kid, algorithm and signing key of the active signer
+func (s *KeyStore) Current() (kid string, alg JWTAlgorithm, signKey interface{}, err error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entry, ok := s.keys[s.currentKid]
+	if !ok {
+		return "", "", nil, fmt.Errorf("no current signing key configured")
+	}
+	return s.currentKid, entry.alg, entry.signKey, nil
+}
+
+// Verify returns the verification key and algorithm registered for kid, provided
+// it is still active (current, or retired within the grace period)
+func (s *KeyStore) Verify(kid string) (interface{}, JWTAlgorithm, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entry, ok := s.keys[kid]
+	if !ok || s.isExpired(entry) {
+		return nil, "", false
+	}
+	return entry.verifyKey, entry.alg, true
+}
+
+// ActiveVerifyKeys returns every key (current or within its grace period) that
+// verification may fall back to when a token carries no (or an unknown) kid
+func (s *KeyStore) ActiveVerifyKeys() map[string]interface{} {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	out := make(map[string]interface{}, len(s.keys))
+	for kid, entry := range s.keys {
+		if s.isExpired(entry) {
+			continue
+		}
+		out[kid] = entry.verifyKey
+	}
+	return out
+}
+
+func (s *KeyStore) isExpired(entry *keyEntry) bool {
+	return !entry.retiredAt.IsZero() && time.Since(entry.retiredAt) > s.gracePeriod
+}
+
+// RotateKey installs newKid as the current signer, retiring the previous signer
+// to verify-only. The old key keeps verifying tokens for the store's grace period.
+func (s *KeyStore) RotateKey(newKid string, alg JWTAlgorithm, signKey, verifyKey interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if prev, ok := s.keys[s.currentKid]; ok && prev.retiredAt.IsZero() {
+		prev.retiredAt = time.Now()
+		prev.signKey = nil
+	}
+
+	s.keys[newKid] = &keyEntry{alg: alg, signKey: signKey, verifyKey: verifyKey}
+	s.currentKid = newKid
+	s.bumpLocked()
+}
+
+// RetireKey immediately marks kid as verify-only, starting its grace period clock.
+// It refuses to retire the current signer - rotate to a replacement key first.
+func (s *KeyStore) RetireKey(kid string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if kid == s.currentKid {
+		return fmt.Errorf("cannot retire the current signing key (%s), rotate to a replacement first", kid)
+	}
+
+	entry, ok := s.keys[kid]
+	if !ok {
+		return fmt.Errorf("key (%s) not found", kid)
+	}
+	if entry.retiredAt.IsZero() {
+		entry.retiredAt = time.Now()
+	}
+	entry.signKey = nil
+	s.bumpLocked()
+	return nil
+}
+
+// LoadFromDirectory scans path for `<kid>.pem` private key files (PKCS#1 for RSA,
+// SEC1 for EC) and registers each key, ready to be promoted, so operators can
+// stage keys by dropping new PEM files into a directory and calling this again;
+// the directory is scanned once per call, not watched. A loaded
+// key is neither current nor retired - its grace period only starts once
+// RotateKey actually supersedes it with a new current signer.
+func (s *KeyStore) LoadFromDirectory(path string, alg JWTAlgorithm) error {
+	files, err := ioutil.ReadDir(path)
+	if err != nil {
+		return fmt.Errorf("error reading key directory - %v", err)
+	}
+
+	isEC := strings.HasPrefix(string(alg), "ES")
+
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ".pem") {
+			continue
+		}
+
+		kid := strings.TrimSuffix(file.Name(), ".pem")
+		raw, err := ioutil.ReadFile(filepath.Join(path, file.Name()))
+		if err != nil {
+			return fmt.Errorf("error reading key file (%s) - %v", file.Name(), err)
+		}
+
+		var signKey, verifyKey interface{}
+		if isEC {
+			privateKey, err := jwt.ParseECPrivateKeyFromPEM(raw)
+			if err != nil {
+				return fmt.Errorf("error parsing ec key (%s) - %v", file.Name(), err)
+			}
+			signKey, verifyKey = privateKey, &privateKey.PublicKey
+		} else {
+			privateKey, err := jwt.ParseRSAPrivateKeyFromPEM(raw)
+			if err != nil {
+				return fmt.Errorf("error parsing rsa key (%s) - %v", file.Name(), err)
+			}
+			signKey, verifyKey = privateKey, &privateKey.PublicKey
+		}
+
+		s.mu.Lock()
+		if existing, ok := s.keys[kid]; ok {
+			existing.signKey = signKey
+			existing.verifyKey = verifyKey
+		} else {
+			// retiredAt stays zero: a freshly loaded key isn't retired, just not
+			// current yet - its grace period should only start counting down once
+			// RotateKey actually supersedes it
+			s.keys[kid] = &keyEntry{alg: alg, signKey: signKey, verifyKey: verifyKey}
+		}
+		s.bumpLocked()
+		s.mu.Unlock()
+	}
+
+	return nil
+}
+
+// RotateKey installs a new signing key as the current signer for the module,
+// retiring the previous one to verify-only for the configured grace period.
+// It errors if the module has no local KeyStore - e.g. a runner verifying
+// against a fetched JWKS rather than signing with a key of its own.
+func (m *Module) RotateKey(kid string, signKey, verifyKey interface{}) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.keys == nil {
+		return fmt.Errorf("auth module has no local KeyStore to rotate keys in")
+	}
+
+	m.keys.RotateKey(kid, m.config.SignMethod, signKey, verifyKey)
+	m.kid = kid
+	return nil
+}
+
+// RetireKey immediately makes kid verify-only and starts its grace-period
+// countdown; it keeps verifying tokens until that period ends. It is a no-op error if kid is still current,
+// or if the module has no local KeyStore to retire a key in.
+func (m *Module) RetireKey(kid string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.keys == nil {
+		return fmt.Errorf("auth module has no local KeyStore to retire keys in")
+	}
+
+	return m.keys.RetireKey(kid)
+}
+
+// LoadFromDirectory registers every `<kid>.pem` key found under path in the
+// module's KeyStore, ready to be promoted with RotateKey.
+func (m *Module) LoadFromDirectory(path string) error {
+	m.lock.RLock()
+	keys := m.keys
+	alg := m.config.SignMethod
+	m.lock.RUnlock()
+
+	if keys == nil {
+		return fmt.Errorf("auth module has no local KeyStore to load keys into")
+	}
+	return keys.LoadFromDirectory(path, alg)
+}
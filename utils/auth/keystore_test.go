@@ -0,0 +1,100 @@
+package auth
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestKeyStoreRotateAndGracePeriod(t *testing.T) {
+	store := newKeyStore(50 * time.Millisecond)
+	store.setCurrent("k1", HS256, []byte("k1-secret"), []byte("k1-secret"))
+
+	store.RotateKey("k2", HS256, []byte("k2-secret"), []byte("k2-secret"))
+
+	kid, _, signKey, err := store.Current()
+	if err != nil {
+		t.Fatalf("error reading current key - %v", err)
+	}
+	if kid != "k2" {
+		t.Fatalf("expected current kid k2, got %s", kid)
+	}
+	if string(signKey.([]byte)) != "k2-secret" {
+		t.Fatal("unexpected current sign key")
+	}
+
+	// k1 should still verify immediately after rotation, within its grace period
+	if _, _, ok := store.Verify("k1"); !ok {
+		t.Fatal("expected k1 to still be verifiable right after rotation")
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	if _, _, ok := store.Verify("k1"); ok {
+		t.Fatal("expected k1 to have aged out of its grace period")
+	}
+	if _, _, ok := store.Verify("k2"); !ok {
+		t.Fatal("expected the current key k2 to still verify")
+	}
+}
+
+func TestKeyStoreRetireKeyRefusesCurrent(t *testing.T) {
+	store := newKeyStore(time.Hour)
+	store.setCurrent("k1", HS256, []byte("secret"), []byte("secret"))
+
+	if err := store.RetireKey("k1"); err == nil {
+		t.Fatal("expected retiring the current signing key to be rejected")
+	}
+}
+
+func TestKeyStoreLoadFromDirectoryDoesNotStartGracePeriod(t *testing.T) {
+	dir := t.TempDir()
+
+	// the public key is written with a non-.pem suffix so LoadFromDirectory's
+	// `*.pem` scan doesn't also try (and fail) to parse it as a private key
+	if err := GenerateKeyPair(RS256, 0, dir+"/k2.pem", dir+"/k2.pub"); err != nil {
+		t.Fatalf("error generating keypair - %v", err)
+	}
+
+	// a 1ns grace period means k2 would already have aged out by the Verify
+	// below if loading it had started its grace-period clock
+	store := newKeyStore(time.Nanosecond)
+	if err := store.LoadFromDirectory(dir, RS256); err != nil {
+		t.Fatalf("error loading directory - %v", err)
+	}
+
+	if _, _, ok := store.Verify("k2"); !ok {
+		t.Fatal("expected a freshly loaded key to verify before ever being rotated in")
+	}
+}
+
+func TestModuleRotateKeyRetireKeyGuardRunnerMode(t *testing.T) {
+	dir := t.TempDir()
+	privPath := dir + "/priv.pem"
+	pubPath := dir + "/pub.pem"
+	if err := GenerateKeyPair(RS256, 0, privPath, pubPath); err != nil {
+		t.Fatalf("error generating keypair - %v", err)
+	}
+
+	server, err := New(&Config{SignMethod: RS256, Mode: Server}, pubPath, privPath)
+	if err != nil {
+		t.Fatalf("error creating server module - %v", err)
+	}
+
+	ts := httptest.NewServer(server.JWKSHandler())
+	defer ts.Close()
+
+	// A runner-mode module for an asymmetric algorithm never gets a local
+	// KeyStore - it only ever verifies against the JWKS it fetched.
+	runner, err := New(&Config{SignMethod: RS256, Mode: Runner, JWKSEndpoint: ts.URL}, "", "")
+	if err != nil {
+		t.Fatalf("error creating runner module - %v", err)
+	}
+
+	if err := runner.RotateKey("new-kid", nil, nil); err == nil {
+		t.Fatal("expected RotateKey to error, not panic, on a runner-mode module with no local KeyStore")
+	}
+	if err := runner.RetireKey("new-kid"); err == nil {
+		t.Fatal("expected RetireKey to error, not panic, on a runner-mode module with no local KeyStore")
+	}
+}
@@ -0,0 +1,155 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// watchLongPollTimeout bounds how long the server holds a watch request open
+// waiting for a key set change before replying with the unchanged state
+const watchLongPollTimeout = 30 * time.Second
+
+// watchMinBackoff/watchMaxBackoff bound the runner's reconnect backoff after a
+// failed (or dropped) watch request
+const (
+	watchMinBackoff = 1 * time.Second
+	watchMaxBackoff = 30 * time.Second
+)
+
+// watchClient bounds how long a single long-poll request can take. It must be
+// longer than watchLongPollTimeout (the server may legitimately hold the request
+// open that long) but still finite, otherwise a peer that stops responding mid-
+// request - rather than cleanly erroring or closing the connection - would hang
+// the watch loop forever instead of ever reaching the reconnect/backoff path.
+var watchClient = &http.Client{Timeout: watchLongPollTimeout + 10*time.Second}
+
+// keyVersionHeader carries the KeyStore version of the JWKS in the response, so
+// a watching runner knows what `since` to pass on its next request
+const keyVersionHeader = "X-Key-Version"
+
+// WatchKeysHandler serves a long-poll endpoint: it blocks until the module's key
+// set changes past the version the caller already has (passed as `?since=`), or
+// until watchLongPollTimeout elapses, then responds with the current JWKS. This
+// lets runners learn about a key rotation in well under a second instead of
+// waiting out a fixed poll interval.
+func (m *Module) WatchKeysHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		m.lock.RLock()
+		keys := m.keys
+		m.lock.RUnlock()
+
+		if keys == nil {
+			http.Error(w, "this auth module has no local keys to watch", http.StatusNotFound)
+			return
+		}
+
+		since, _ := strconv.Atoi(r.URL.Query().Get("since"))
+		version, wait := keys.Snapshot()
+		if version <= since {
+			select {
+			case <-wait:
+			case <-time.After(watchLongPollTimeout):
+			case <-r.Context().Done():
+				return
+			}
+		}
+
+		m.lock.RLock()
+		set, err := m.buildJWKS()
+		m.lock.RUnlock()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set(keyVersionHeader, strconv.Itoa(keys.Version()))
+		_ = json.NewEncoder(w).Encode(set)
+	}
+}
+
+// watchKeys subscribes to the server's WatchKeysHandler, updating the runner's
+// cached verify keys the moment the server reports a change. If the watch
+// endpoint isn't configured, it polls the JWKS endpoint instead, waiting out the
+// Cache-Control max-age of the last fetched key set between polls. If the watch
+// stream can't be reached, it falls back to polling the JWKS endpoint on an
+// exponential backoff and keeps retrying the watch stream so it can resume
+// pushing updates once the server recovers.
+func (m *Module) watchKeys() {
+	backoff := watchMinBackoff
+	since := 0
+	for {
+		if m.config.WatchEndpoint == "" {
+			m.lock.RLock()
+			wait := time.Until(m.jwksCache.expiresAt)
+			m.lock.RUnlock()
+			if wait <= 0 {
+				wait = defaultJWKSMaxAge
+			}
+
+			time.Sleep(wait)
+			if err := m.refreshJWKS(); err != nil {
+				log.Printf("auth: error polling jwks - %v", err)
+			}
+			continue
+		}
+
+		version, err := m.watchOnce(since)
+		if err != nil {
+			log.Printf("auth: watch keys stream failed, falling back to polling until it recovers - %v", err)
+			if pollErr := m.refreshJWKS(); pollErr != nil {
+				log.Printf("auth: fallback jwks poll also failed - %v", pollErr)
+			}
+
+			time.Sleep(backoff)
+			backoff *= 2
+			if backoff > watchMaxBackoff {
+				backoff = watchMaxBackoff
+			}
+			continue
+		}
+
+		backoff = watchMinBackoff
+		since = version
+	}
+}
+
+// watchOnce issues a single long-poll request and, on success, installs the
+// returned key set into the runner's jwksCache
+func (m *Module) watchOnce(since int) (int, error) {
+	resp, err := watchClient.Get(fmt.Sprintf("%s?since=%d", m.config.WatchEndpoint, since))
+	if err != nil {
+		return since, fmt.Errorf("error reaching watch endpoint - %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return since, fmt.Errorf("watch endpoint returned status %d", resp.StatusCode)
+	}
+
+	var set JSONWebKeySet
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return since, fmt.Errorf("error decoding watch response - %v", err)
+	}
+
+	keys := make(map[string]interface{}, len(set.Keys))
+	for _, jwk := range set.Keys {
+		key, err := jwk.publicKey()
+		if err != nil {
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+
+	version, _ := strconv.Atoi(resp.Header.Get(keyVersionHeader))
+
+	m.lock.Lock()
+	m.jwksCache = &jwksCacheEntry{keys: keys, expiresAt: time.Now().Add(defaultJWKSMaxAge)}
+	m.lock.Unlock()
+
+	return version, nil
+}